@@ -0,0 +1,148 @@
+package pcsupload
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload/dircache"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/taskframework"
+)
+
+const (
+	// MinBatchUploadJobs 最小并发上传文件数
+	MinBatchUploadJobs = 1
+	// MaxBatchUploadJobs 最大并发上传文件数
+	MaxBatchUploadJobs = 16
+)
+
+// BatchUploader 多文件并发上传器, 通过一个有限大小的worker池并行跑多个 UploadTaskUnit。
+// 目录上传时各文件共用同一个 TaskPrinter 和 UploadStatistic, 并按同时在跑的文件数平分总限速,
+// UploadingDatabase 的写入则通过共享锁串行化, 避免并发写坏断点续传数据库。
+type BatchUploader struct {
+	Units   []*UploadTaskUnit
+	Jobs    int
+	Printer *taskframework.TaskPrinter
+
+	saveMu sync.Mutex
+}
+
+// NewBatchUploader 初始化BatchUploader, jobs会被限制在 [MinBatchUploadJobs, MaxBatchUploadJobs] 之间
+func NewBatchUploader(units []*UploadTaskUnit, jobs int) *BatchUploader {
+	if jobs < MinBatchUploadJobs {
+		jobs = MinBatchUploadJobs
+	}
+	if jobs > MaxBatchUploadJobs {
+		jobs = MaxBatchUploadJobs
+	}
+	if jobs > len(units) && len(units) > 0 {
+		jobs = len(units)
+	}
+
+	return &BatchUploader{
+		Units:   units,
+		Jobs:    jobs,
+		Printer: taskframework.NewTaskPrinter(),
+	}
+}
+
+// Run 启动worker池, 并发执行所有上传单元, 阻塞直至全部完成
+func (bu *BatchUploader) Run() {
+	if len(bu.Units) == 0 {
+		return
+	}
+
+	statistic := NewUploadStatistic()
+
+	// 总限速按并发数平分给每个文件, 避免单个文件把整个速率配额占满
+	var rateShare int64
+	if pcsconfig.Config.MaxUploadRate > 0 {
+		rateShare = pcsconfig.Config.MaxUploadRate / int64(bu.Jobs)
+		if rateShare <= 0 {
+			rateShare = 1
+		}
+	}
+
+	for _, utu := range bu.Units {
+		taskInfo := taskframework.NewTaskInfo(utu.LocalFileChecksum.Path)
+		utu.SetTaskInfo(taskInfo)
+		utu.SetPrinter(bu.Printer.GetPrintFunc(taskInfo.Id()))
+		utu.UploadStatistic = statistic
+		utu.MaxRateOverride = rateShare
+		utu.saveMu = &bu.saveMu
+
+		bu.Printer.SetTaskInfo(taskInfo)
+	}
+
+	// 批量上传时只保留Jobs个活跃行, 其余折叠进汇总行, 避免刷屏
+	bu.Printer.MaxActiveRows = bu.Jobs
+
+	go bu.Printer.Start()
+	defer bu.Printer.Stop()
+
+	unitChan := make(chan *UploadTaskUnit)
+	var wg sync.WaitGroup
+	for i := 0; i < bu.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for utu := range unitChan {
+				bu.runOne(utu)
+			}
+		}()
+	}
+
+	for _, utu := range bu.Units {
+		unitChan <- utu
+	}
+	close(unitChan)
+	wg.Wait()
+
+	bu.closeDirCaches()
+}
+
+// closeDirCaches 目录上传时所有unit共享同一个*dircache.Cache, 批次结束后统一Save一次做收尾压缩
+// (把追加写入过程中产生的重复key去重)并关闭追加文件句柄, 避免句柄和磁盘空间泄漏
+func (bu *BatchUploader) closeDirCaches() {
+	seen := make(map[*dircache.Cache]bool)
+	for _, utu := range bu.Units {
+		if utu.DirCache == nil || seen[utu.DirCache] {
+			continue
+		}
+		seen[utu.DirCache] = true
+		utu.DirCache.Save()
+		utu.DirCache.Close()
+	}
+}
+
+// runOne 驱动单个上传单元走完完整的生命周期(含重试), 与既有单文件顺序上传走的是同一套
+// Run/OnRetry/OnSuccess/OnFailed/OnComplete/RetryWait约定, 只是由worker池并发调用。
+// 结束后把结果状态同步回Printer中该单元的专属行(以taskInfo.Id()为key, 与注册时保持一致)。
+func (bu *BatchUploader) runOne(utu *UploadTaskUnit) {
+	id := utu.taskInfo.Id()
+	maxRetry := utu.taskInfo.MaxRetry()
+
+	bu.Printer.StatChange(id, taskframework.TaskRun)
+
+	var result *taskframework.TaskUnitRunResult
+	for attempt := 0; ; attempt++ {
+		result = utu.Run()
+		if result != nil && result.Succeed {
+			utu.OnSuccess(result)
+			break
+		}
+		if result == nil || !result.NeedRetry || attempt >= maxRetry {
+			utu.OnFailed(result)
+			break
+		}
+		utu.OnRetry(result)
+		time.Sleep(utu.RetryWait())
+	}
+	utu.OnComplete(result)
+
+	if result != nil && result.Succeed {
+		bu.Printer.StatChange(id, taskframework.TaskDone)
+	} else {
+		bu.Printer.StatChange(id, taskframework.TaskFail)
+	}
+}
@@ -0,0 +1,192 @@
+// Package dircache 实现目录级别的本地文件哈希缓存, 用于加速大目录重复上传/重试时的秒传探测。
+// 设计思路参考了 qshell 的 DirCache: 之后的上传只需按 (相对路径, 大小, 修改时间) 命中缓存, 避免重复读取整个文件来计算MD5。
+// 本包不遍历目录: 缓存项只在上传过程中某个文件的MD5/SliceMD5算出后经 Put 逐条写入, 未被上传过的文件不在缓存中。
+package dircache
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry 缓存中的一条文件记录
+type Entry struct {
+	RelPath    string `json:"relpath"`
+	Size       int64  `json:"size"`
+	Mtime100Ns int64  `json:"mtime_100ns"` // 修改时间, 精度100ns, 与qshell DirCache保持一致
+	MD5        string `json:"md5"`
+	SliceMD5   string `json:"slice_md5"`
+}
+
+const cacheFilePrefix = "pcs_localcache_"
+
+// CacheFileName 根据目录的绝对路径计算出缓存文件名
+func CacheFileName(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	sum := md5.Sum([]byte(abs))
+	return cacheFilePrefix + hex.EncodeToString(sum[:]) + ".jsonl"
+}
+
+// Cache 目录级别的本地文件哈希缓存。目录上传会把同一个*Cache实例共享给该目录下所有文件
+// 对应的UploadTaskUnit, 而BatchUploader会并发跑这些unit, 因此Lookup/Put/Save都需要加锁。
+type Cache struct {
+	Dir string
+	TTL time.Duration // 缓存有效期, 0表示永不过期
+
+	mu      sync.Mutex
+	path    string
+	entries map[string]*Entry
+	dirty   bool
+	file    *os.File // 追加写入用的文件句柄, 懒加载
+}
+
+func entryKey(relPath string, size, mtime100Ns int64) string {
+	return fmt.Sprintf("%s|%d|%d", relPath, size, mtime100Ns)
+}
+
+// Load 加载dir目录下的缓存文件。缓存不存在或已过期时返回一个空的Cache, 调用方按需重新填充。
+func Load(dir string, ttl time.Duration) *Cache {
+	c := &Cache{
+		Dir:     dir,
+		TTL:     ttl,
+		path:    filepath.Join(dir, CacheFileName(dir)),
+		entries: make(map[string]*Entry),
+	}
+
+	fi, err := os.Stat(c.path)
+	if err != nil {
+		return c
+	}
+	if ttl > 0 && time.Since(fi.ModTime()) > ttl {
+		// 缓存已过期, 当作未命中处理
+		return c
+	}
+
+	f, err := os.Open(c.path)
+	if err != nil {
+		return c
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := &Entry{}
+		if err = json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			continue
+		}
+		c.entries[entryKey(entry.RelPath, entry.Size, entry.Mtime100Ns)] = entry
+	}
+	return c
+}
+
+// Lookup 按相对路径, 大小和修改时间查找缓存项, 三者均一致才视为命中
+func (c *Cache) Lookup(relPath string, size, mtime100Ns int64) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[entryKey(relPath, size, mtime100Ns)]
+	return e, ok
+}
+
+// Put 写入或更新一条缓存项, 并立即把这一条追加到缓存文件末尾。
+// 缓存文件是JSONL格式, 追加单条远比每次都重写整个map便宜, 目录文件数越多差距越大。
+func (c *Cache) Put(e *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[entryKey(e.RelPath, e.Size, e.Mtime100Ns)] = e
+	c.dirty = true
+	c.appendLocked(e)
+}
+
+// appendLocked 把单条entry追加写入缓存文件, 调用方需持有c.mu
+func (c *Cache) appendLocked(e *Entry) {
+	if c.file == nil {
+		f, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		c.file = f
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	c.file.Write(data)
+	c.file.WriteString("\n")
+}
+
+// Save 将缓存项整体重写落盘, 用于压缩追加写入过程中积累的重复key(同一文件被多次Put)。
+// 日常的单条写入由Put内部的追加完成, 不需要调用方显式Save；Save只用于批次结束时的收尾压缩。
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+
+	f, err := os.Create(c.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, e := range c.entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		w.Write(data)
+		w.WriteString("\n")
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// Close 关闭追加写入用的文件句柄, 在一次目录上传结束后调用, 避免句柄泄漏
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// Invalidate 将dir目录下的旧缓存文件重命名为 .old, 用于 --refresh-cache 强制刷新
+func Invalidate(dir string) error {
+	p := filepath.Join(dir, CacheFileName(dir))
+	if _, err := os.Stat(p); os.IsNotExist(err) {
+		return nil
+	}
+	return os.Rename(p, p+".old")
+}
+
+// Mtime100Ns 将文件修改时间换算为100ns精度的整数, 与 Entry.Mtime100Ns 的单位保持一致
+func Mtime100Ns(t time.Time) int64 {
+	return t.UnixNano() / 100
+}
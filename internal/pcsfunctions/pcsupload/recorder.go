@@ -0,0 +1,79 @@
+package pcsupload
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
+)
+
+// Recorder 上传断点记录器, 用于保存/恢复分片上传的进度信息,
+// 设计参考七牛 ResumeUploader 和阿里云 OSS UploadFile 的 checkpoint 机制
+type Recorder interface {
+	// Get 读取key对应的断点记录, 不存在时返回错误
+	Get(key string) ([]byte, error)
+	// Set 保存key对应的断点记录
+	Set(key string, data []byte) error
+	// Delete 删除key对应的断点记录
+	Delete(key string) error
+}
+
+// FileRecorder 基于本地文件的 Recorder 实现, 每个上传任务对应 Dir 下的一个 json 文件
+type FileRecorder struct {
+	Dir string
+}
+
+// NewFileRecorder 初始化FileRecorder, dir 为断点记录文件的存放目录
+func NewFileRecorder(dir string) *FileRecorder {
+	return &FileRecorder{Dir: dir}
+}
+
+func (fr *FileRecorder) recordPath(key string) string {
+	return filepath.Join(fr.Dir, key+".json")
+}
+
+// Get 读取key对应的断点记录
+func (fr *FileRecorder) Get(key string) ([]byte, error) {
+	return ioutil.ReadFile(fr.recordPath(key))
+}
+
+// Set 保存key对应的断点记录
+func (fr *FileRecorder) Set(key string, data []byte) error {
+	if err := os.MkdirAll(fr.Dir, 0744); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fr.recordPath(key), data, 0644)
+}
+
+// Delete 删除key对应的断点记录, 文件不存在时不视为错误
+func (fr *FileRecorder) Delete(key string) error {
+	err := os.Remove(fr.recordPath(key))
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// RecorderKey 根据本地文件的绝对路径, 大小和修改时间计算出一个稳定的断点记录key,
+// 使同一份文件在不同进程或不同次运行中都能对应到同一条记录。
+//
+// 该key在prepareFile阶段(即LocalFileChecksum.Sum()计算MD5/SliceMD5之前)就需要确定,
+// 以便先查询Recorder再决定是否需要重新读取整个文件, 因此不能依赖分片MD5。
+func RecorderKey(lfc *checksum.LocalFileChecksum) string {
+	abs, err := filepath.Abs(lfc.Path)
+	if err != nil {
+		abs = lfc.Path
+	}
+
+	var mtime int64
+	if fi, serr := os.Stat(lfc.Path); serr == nil {
+		mtime = fi.ModTime().UnixNano()
+	}
+
+	sum := md5.Sum([]byte(fmt.Sprintf("%s|%d|%d", abs, lfc.Length, mtime)))
+	return hex.EncodeToString(sum[:])
+}
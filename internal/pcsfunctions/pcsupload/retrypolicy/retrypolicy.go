@@ -0,0 +1,154 @@
+// Package retrypolicy 统一百度网盘上传错误的分类与重试策略, 取代原先散落在
+// rapidUpload/upload/OnError 中的 switch pcsError.GetRemoteErrCode() 逻辑。
+package retrypolicy
+
+import (
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+)
+
+// Action 描述上传遇到错误后应当采取的动作
+type Action int
+
+const (
+	// ActionRetry 按原状态直接重试
+	ActionRetry Action = iota
+	// ActionRetryAfterReauth 需要重新登录/刷新凭证后再重试
+	ActionRetryAfterReauth
+	// ActionRetryWithNewUploadID 服务端分片状态已失效, 需要丢弃本地断点, 申请新的uploadid重新上传
+	ActionRetryWithNewUploadID
+	// ActionFatal 不可恢复的错误, 不再重试
+	ActionFatal
+	// ActionSkip 无需重试也无需视为失败, 例如目标文件已存在, 或该错误码在当前场景下并非真正的错误
+	ActionSkip
+)
+
+// Policy 根据上传过程中产生的错误给出下一步动作和建议的等待时间
+type Policy interface {
+	Classify(err error) (action Action, wait time.Duration)
+}
+
+// DefaultPolicy 默认重试策略: 对可重试错误做指数退避(附带抖动), 并识别常见的百度错误码
+type DefaultPolicy struct {
+	BaseWait time.Duration // 首次重试的基础等待时间
+	MaxWait  time.Duration // 等待时间上限
+
+	// RenamePolicy 对应 UploadTaskUnit.Policy(即百度 ondup 参数), 用于判断31061重名冲突
+	// 是否属于预期行为: 为"skip"时说明用户本就希望遇到同名文件时跳过, 不应视为上传失败
+	RenamePolicy string
+
+	attempt int
+}
+
+// NewDefaultPolicy 返回一个基础等待1秒, 上限30秒的默认重试策略
+func NewDefaultPolicy() *DefaultPolicy {
+	return &DefaultPolicy{
+		BaseWait: time.Second,
+		MaxWait:  30 * time.Second,
+	}
+}
+
+// Reset 清空已记录的重试次数, 用于一个新的上传任务重新开始计时
+func (p *DefaultPolicy) Reset() {
+	p.attempt = 0
+}
+
+// backoff 计算本次重试应等待的时间: 指数递增, 叠加随机抖动防止雪崩
+func (p *DefaultPolicy) backoff() time.Duration {
+	wait := p.BaseWait << uint(p.attempt)
+	if wait <= 0 || wait > p.MaxWait {
+		wait = p.MaxWait
+	}
+	p.attempt++
+
+	jitter := time.Duration(rand.Int63n(int64(wait)/2 + 1))
+	return wait/2 + jitter
+}
+
+// Classify 对上传过程中的err进行分类, 返回应采取的动作和建议等待时间
+func (p *DefaultPolicy) Classify(err error) (Action, time.Duration) {
+	if err == nil {
+		p.Reset()
+		return ActionSkip, 0
+	}
+
+	pcsErr, ok := err.(pcserror.Error)
+	if !ok {
+		// 未知错误类型(非预期的), 默认快速失败, 避免把代码bug/解析错误当成可重试的瞬时故障;
+		// 只有能确认是网络超时的Go错误才按瞬时故障处理
+		if netErr, isNetErr := err.(net.Error); isNetErr && netErr.Timeout() {
+			return ActionRetry, p.backoff()
+		}
+		return ActionFatal, 0
+	}
+
+	switch pcsErr.GetErrType() {
+	case pcserror.ErrTypeRemoteError:
+		return p.classifyRemoteErrCode(pcsErr.GetRemoteErrCode())
+	case pcserror.ErrTypeNetError:
+		return p.classifyNetError(pcsErr)
+	default:
+		return ActionFatal, 0
+	}
+}
+
+func (p *DefaultPolicy) classifyRemoteErrCode(code int) (Action, time.Duration) {
+	switch code {
+	case 31363:
+		// block miss in superfile2, 上传状态已在服务端过期, 需丢弃本地断点重新申请uploadid
+		return ActionRetryWithNewUploadID, p.backoff()
+	case 31061:
+		// 已存在重名文件: Policy(百度ondup参数)为skip时这是预期行为, 应跳过而非视为失败;
+		// 其他Policy下服务端理应已经处理了重名(覆盖/重命名), 此处出现说明不可恢复
+		if p.RenamePolicy == "skip" {
+			return ActionSkip, 0
+		}
+		return ActionFatal, 0
+	case 31112:
+		// 超出配额, 网盘容量已满, 无法恢复
+		return ActionFatal, 0
+	case 31066:
+		// 目录/文件不存在(常见于秒传前探测父目录的文件列表), 并非真正的错误, 忽略即可
+		return ActionSkip, 0
+	case -6, 110, 111:
+		// 凭证过期/无效, 需要重新登录后再重试
+		return ActionRetryAfterReauth, p.backoff()
+	default:
+		return ActionRetry, p.backoff()
+	}
+}
+
+func (p *DefaultPolicy) classifyNetError(pcsErr pcserror.Error) (Action, time.Duration) {
+	msg := pcsErr.GetError().Error()
+	switch {
+	case strings.Contains(msg, "413 Request Entity Too Large"):
+		// 请求实体过大, 重试无意义
+		return ActionFatal, 0
+	case strings.Contains(msg, "429"), hasRetryableHTTPStatus(msg):
+		// 限流或服务端临时错误, 退避重试
+		return ActionRetry, p.backoff()
+	default:
+		// 网络超时/连接重置等, 按可恢复错误处理
+		return ActionRetry, p.backoff()
+	}
+}
+
+// IsQuotaExceeded 判断err是否为百度网盘配额已满(31112)。秒传和正常上传两条路径都需要在
+// ActionFatal之外额外区分出这一种情况以展示更准确的提示文案, 因此单独导出, 避免两处各写一份判断逻辑
+func IsQuotaExceeded(err error) bool {
+	pcsErr, ok := err.(pcserror.Error)
+	return ok && pcsErr.GetErrType() == pcserror.ErrTypeRemoteError && pcsErr.GetRemoteErrCode() == 31112
+}
+
+func hasRetryableHTTPStatus(msg string) bool {
+	for _, code := range []string{"500", "502", "503", "504"} {
+		if strings.Contains(msg, code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package retrypolicy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+)
+
+// fakeNetError 用于模拟实现了net.Error的超时错误, 不依赖真实网络连接
+type fakeNetError struct {
+	timeout bool
+}
+
+func (e *fakeNetError) Error() string   { return "fake net error" }
+func (e *fakeNetError) Timeout() bool   { return e.timeout }
+func (e *fakeNetError) Temporary() bool { return e.timeout }
+
+// fakePCSError 最小化实现pcserror.Error, 用于在不依赖真实百度接口的情况下构造各类错误码
+type fakePCSError struct {
+	errType    pcserror.ErrType
+	remoteCode int
+	err        error
+}
+
+func (e *fakePCSError) Error() string                { return e.err.Error() }
+func (e *fakePCSError) GetErrType() pcserror.ErrType { return e.errType }
+func (e *fakePCSError) GetRemoteErrCode() int        { return e.remoteCode }
+func (e *fakePCSError) GetError() error              { return e.err }
+func (e *fakePCSError) SetJSONError(err error)       {}
+func (e *fakePCSError) SetNetError(err error)        {}
+func (e *fakePCSError) SetRemoteError()              {}
+func (e *fakePCSError) GetOperation() string         { return "" }
+func (e *fakePCSError) GetRemoteErrMsg() string      { return "" }
+
+func remoteErr(code int) error {
+	return &fakePCSError{
+		errType:    pcserror.ErrTypeRemoteError,
+		remoteCode: code,
+		err:        errors.New("remote error"),
+	}
+}
+
+func netErr(msg string) error {
+	return &fakePCSError{
+		errType: pcserror.ErrTypeNetError,
+		err:     errors.New(msg),
+	}
+}
+
+func TestDefaultPolicy_Classify(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		renamePolicy string
+		wantAction   Action
+	}{
+		{"nil error resets and skips", nil, "", ActionSkip},
+		{"unknown error type fails fast", errors.New("boom"), "", ActionFatal},
+		{"timeout go error retries", &fakeNetError{timeout: true}, "", ActionRetry},
+		{"non-timeout go error fails fast", &fakeNetError{timeout: false}, "", ActionFatal},
+		{"31363 block miss restarts with new upload id", remoteErr(31363), "", ActionRetryWithNewUploadID},
+		{"31061 rename collision is fatal by default", remoteErr(31061), "", ActionFatal},
+		{"31061 rename collision skips when policy is skip", remoteErr(31061), "skip", ActionSkip},
+		{"31112 quota exceeded is fatal", remoteErr(31112), "", ActionFatal},
+		{"31066 missing dir is skipped", remoteErr(31066), "", ActionSkip},
+		{"-6 invalid credential retries after reauth", remoteErr(-6), "", ActionRetryAfterReauth},
+		{"110 invalid access token retries after reauth", remoteErr(110), "", ActionRetryAfterReauth},
+		{"111 expired access token retries after reauth", remoteErr(111), "", ActionRetryAfterReauth},
+		{"unrecognized remote code retries", remoteErr(99999), "", ActionRetry},
+		{"413 request entity too large is fatal", netErr("413 Request Entity Too Large"), "", ActionFatal},
+		{"429 too many requests retries", netErr("429 Too Many Requests"), "", ActionRetry},
+		{"502 bad gateway retries", netErr("502 Bad Gateway"), "", ActionRetry},
+		{"generic network error retries", netErr("connection reset by peer"), "", ActionRetry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewDefaultPolicy()
+			p.RenamePolicy = tt.renamePolicy
+
+			action, _ := p.Classify(tt.err)
+			if action != tt.wantAction {
+				t.Fatalf("Classify() action = %v, want %v", action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestDefaultPolicy_BackoffStaysWithinBounds(t *testing.T) {
+	p := NewDefaultPolicy()
+	p.BaseWait = time.Millisecond
+	p.MaxWait = 10 * time.Millisecond
+
+	for i := 0; i < 10; i++ {
+		_, wait := p.Classify(remoteErr(0)) // 未分类的码按ActionRetry处理, 触发backoff
+		if wait <= 0 || wait > p.MaxWait {
+			t.Fatalf("backoff wait %v out of bounds (0, %v]", wait, p.MaxWait)
+		}
+	}
+}
+
+func TestDefaultPolicy_IsQuotaExceeded(t *testing.T) {
+	if !IsQuotaExceeded(remoteErr(31112)) {
+		t.Fatal("IsQuotaExceeded() = false for remote code 31112, want true")
+	}
+	if IsQuotaExceeded(remoteErr(31061)) {
+		t.Fatal("IsQuotaExceeded() = true for remote code 31061, want false")
+	}
+	if IsQuotaExceeded(errors.New("not a pcs error")) {
+		t.Fatal("IsQuotaExceeded() = true for a non-pcserror.Error, want false")
+	}
+}
@@ -0,0 +1,67 @@
+package pcsupload
+
+import (
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload/dircache"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/taskframework"
+)
+
+// DirCacheTTL 目录哈希缓存的默认有效期, 超过该时长的缓存文件在下次上传时按未命中处理
+const DirCacheTTL = 7 * 24 * time.Hour
+
+// UploadArgs 汇总 Recorder/DirCache/BatchUploader/TaskPrinter.Mode 这几个子系统的配置项,
+// 各字段按名称对应以下参数名:
+//
+//	--resume-dir    -> ResumeDir
+//	--refresh-cache -> RefreshCache
+//	--jobs          -> Jobs
+//	--output        -> Output
+//
+// 本包不注册也不解析这些命令行参数; 由调用方填充这个结构体, 再交给本包的Prepare*/New*方法去装配各个UploadTaskUnit。
+type UploadArgs struct {
+	ResumeDir    string // 断点记录文件存放目录, 为空表示不启用Recorder
+	RefreshCache bool   // 强制丢弃目录哈希缓存重新扫描
+	Jobs         int    // 并发上传文件数, <=1时等价于原有的顺序上传
+	Output       string // tty/plain/json, 对应taskframework.Mode, 无法识别时按tty(ModeAuto)处理
+}
+
+// ParseOutputMode 解析--output的取值, 无法识别的值回退到ModeAuto(按标准输出是否为终端自动判断)
+func ParseOutputMode(output string) taskframework.Mode {
+	switch output {
+	case "plain":
+		return taskframework.ModePlain
+	case "json":
+		return taskframework.ModeJSON
+	default:
+		return taskframework.ModeAuto
+	}
+}
+
+// PrepareUnit 按UploadArgs给单个UploadTaskUnit装配断点记录器和目录哈希缓存。
+// dirCache为目录上传时由调用方通过LoadDirCache统一加载并在同目录下的文件间共享的缓存实例,
+// 单文件上传(不存在共享目录缓存)传nil即可。
+func (args *UploadArgs) PrepareUnit(utu *UploadTaskUnit, dirCache *dircache.Cache, relPath string) {
+	if args.ResumeDir != "" {
+		utu.Recorder = NewFileRecorder(args.ResumeDir)
+	}
+	if dirCache != nil {
+		utu.DirCache = dirCache
+		utu.RelPath = relPath
+	}
+}
+
+// LoadDirCache 为目录上传加载本地哈希缓存; RefreshCache为true时先令旧缓存失效, 相当于--refresh-cache
+func (args *UploadArgs) LoadDirCache(dir string) *dircache.Cache {
+	if args.RefreshCache {
+		dircache.Invalidate(dir)
+	}
+	return dircache.Load(dir, DirCacheTTL)
+}
+
+// NewBatchUploader 按Jobs构建BatchUploader, 并把--output对应的Mode设置到其Printer上
+func (args *UploadArgs) NewBatchUploader(units []*UploadTaskUnit) *BatchUploader {
+	bu := NewBatchUploader(units, args.Jobs)
+	bu.Printer.Mode = ParseOutputMode(args.Output)
+	return bu
+}
@@ -3,16 +3,19 @@ package pcsupload
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"path"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
-	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload/dircache"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload/retrypolicy"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/taskframework"
@@ -35,17 +38,28 @@ type (
 		PCS               *baidupcs.BaiduPCS
 		UploadingDatabase *UploadingDatabase // 数据库
 		Parallel          int
-		NoRapidUpload     bool   // 禁用秒传
-		NoSplitFile       bool   // 禁用分片上传
-		Policy            string // 上传重名文件策略
+		NoRapidUpload     bool     // 禁用秒传
+		NoSplitFile       bool     // 禁用分片上传
+		Policy            string   // 上传重名文件策略
+		Recorder          Recorder // 断点记录器, 为nil时不启用
+
+		DirCache *dircache.Cache // 目录哈希缓存, 为nil时不启用
+		RelPath  string          // 相对于目录上传根目录的路径, 用于匹配DirCache中的记录
+
+		RetryPolicy retrypolicy.Policy // 重试策略, 为nil时使用retrypolicy.NewDefaultPolicy()
+
+		MaxRateOverride int64 // 限速覆盖值, BatchUploader并发上传多个文件时用于分摊总限速, 0表示使用pcsconfig.Config.MaxUploadRate
 
 		UploadStatistic *UploadStatistic
 
-		taskInfo *taskframework.TaskInfo
-		printer  func(string, ...interface{})
-		panDir   string
-		panFile  string
-		state    *uploader.InstanceState
+		taskInfo    *taskframework.TaskInfo
+		printer     func(string, ...interface{})
+		panDir      string
+		panFile     string
+		state       *uploader.InstanceState
+		recorderKey string
+		retryWait   time.Duration
+		saveMu      *sync.Mutex // 多文件并发上传时, 用于串行化UploadingDatabase.Save()
 	}
 )
 
@@ -89,6 +103,19 @@ func (utu *UploadTaskUnit) prepareFile() {
 	utu.panDir = path.Clean(panDir)
 	utu.panFile = panFile
 
+	// 优先查询断点记录器, 命中时可跳过 UploadingDatabase 和秒传探测, 直接从记录的分片状态继续上传
+	if utu.Recorder != nil {
+		utu.recorderKey = RecorderKey(utu.LocalFileChecksum)
+		if data, err := utu.Recorder.Get(utu.recorderKey); err == nil && len(data) > 0 {
+			state := &uploader.InstanceState{}
+			if err = json.Unmarshal(data, state); err == nil {
+				utu.state = state
+				utu.Step = StepUploadUpload
+				return
+			}
+		}
+	}
+
 	// 检测断点续传
 	utu.state = utu.UploadingDatabase.Search(&utu.LocalFileChecksum.LocalFileMeta)
 	if utu.state != nil || utu.LocalFileChecksum.LocalFileMeta.MD5 != nil { // 读取到了md5
@@ -114,28 +141,20 @@ func (utu *UploadTaskUnit) prepareFile() {
 func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework.TaskUnitRunResult) {
 	utu.Step = StepUploadRapidUpload
 
-	// TODO: 建立一个通过百度错误码判断重试的函数
 	result = &taskframework.TaskUnitRunResult{}
 
 	fdl, pcsError := utu.PCS.CacheFilesDirectoriesList(utu.panDir, baidupcs.DefaultOrderOptions)
 	if pcsError != nil {
-		switch pcsError.GetErrType() {
-		case pcserror.ErrTypeRemoteError:
-			switch pcsError.GetRemoteErrCode() {
-			case 31066:
-			// file does not exist
-			// 不缓存文件夹
-			default:
-				// 其他百度服务器错误, 不重试
-				result.ResultMessage = "获取文件列表错误"
-				result.Err = pcsError
-				return
-			}
+		switch action, wait := utu.retryPolicy().Classify(pcsError); action {
+		case retrypolicy.ActionSkip:
+			// 例如31066(文件夹不存在), 不缓存文件夹, 不影响秒传流程继续进行
 		default:
-			// 未知错误, 重试
 			result.ResultMessage = "获取文件列表错误"
-			result.NeedRetry = true
 			result.Err = pcsError
+			if action == retrypolicy.ActionRetry || action == retrypolicy.ActionRetryAfterReauth || action == retrypolicy.ActionRetryWithNewUploadID {
+				result.NeedRetry = true
+				utu.retryWait = wait
+			}
 			return
 		}
 	}
@@ -146,12 +165,15 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 	}
 
 	// 经测试, 文件的 crc32 值并非秒传文件所必需
-	err := utu.LocalFileChecksum.Sum(checksum.CHECKSUM_MD5 | checksum.CHECKSUM_SLICE_MD5)
-	if err != nil {
-		// 不重试
-		result.ResultMessage = "计算文件秒传信息错误"
-		result.Err = err
-		return
+	if !utu.fillFromDirCache() {
+		err := utu.LocalFileChecksum.Sum(checksum.CHECKSUM_MD5 | checksum.CHECKSUM_SLICE_MD5)
+		if err != nil {
+			// 不重试
+			result.ResultMessage = "计算文件秒传信息错误"
+			result.Err = err
+			return
+		}
+		utu.updateDirCache()
 	}
 
 	// 检测缓存, 通过文件的md5值判断本地文件和网盘文件是否一样
@@ -178,22 +200,26 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 		return
 	}
 
-	// 判断配额是否已满
-	switch pcsError.GetErrType() {
-	// 远程服务器错误
-	case pcserror.ErrTypeRemoteError:
-		switch pcsError.GetRemoteErrCode() {
-		case 31112: //exceed quota
+	// 判断配额是否已满, 以及是否需要重新登录; 其余情况一律转入正常上传流程重试
+	switch action, wait := utu.retryPolicy().Classify(pcsError); action {
+	case retrypolicy.ActionFatal:
+		if retrypolicy.IsQuotaExceeded(pcsError) {
 			result.ResultMessage = "秒传失败, 超出配额, 网盘容量已满"
 			return
 		}
+	case retrypolicy.ActionRetryAfterReauth:
+		result.ResultMessage = "秒传失败, 登录状态已失效"
+		result.Err = pcsError
+		result.NeedRetry = true
+		utu.retryWait = wait
+		return
 	}
 
 	utu.Printf("秒传失败, 开始上传文件...")
 
 	// 保存秒传信息
 	utu.UploadingDatabase.UpdateUploading(&utu.LocalFileChecksum.LocalFileMeta, nil)
-	utu.UploadingDatabase.Save()
+	utu.saveUploadingDatabase()
 	isContinue = true
 	return
 }
@@ -210,10 +236,15 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 		blockSize = getBlockSize(utu.LocalFileChecksum.Length)
 	}
 
+	maxRate := pcsconfig.Config.MaxUploadRate
+	if utu.MaxRateOverride > 0 {
+		maxRate = utu.MaxRateOverride
+	}
+
 	muer := uploader.NewMultiUploader(NewPCSUpload(utu.PCS, utu.SavePath), rio.NewFileReaderAtLen64(utu.LocalFileChecksum.GetFile()), &uploader.MultiUploaderConfig{
 		Parallel:  utu.Parallel,
 		BlockSize: blockSize,
-		MaxRate:   pcsconfig.Config.MaxUploadRate,
+		MaxRate:   maxRate,
 		Policy:    utu.Policy,
 	})
 
@@ -225,7 +256,8 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 		select {
 		case <-updateChan:
 			utu.UploadingDatabase.UpdateUploading(&utu.LocalFileChecksum.LocalFileMeta, muer.InstanceState())
-			utu.UploadingDatabase.Save()
+			utu.saveUploadingDatabase()
+			utu.saveRecorderState(muer.InstanceState())
 		default:
 		}
 
@@ -244,60 +276,38 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 		// 统计
 		utu.UploadStatistic.AddTotalSize(utu.LocalFileChecksum.Length)
 		utu.UploadingDatabase.Delete(&utu.LocalFileChecksum.LocalFileMeta) // 删除
-		utu.UploadingDatabase.Save()
+		utu.saveUploadingDatabase()
+		utu.deleteRecorderState()
 		result.Succeed = true
 	})
 	muer.OnError(func(err error) {
-		pcsError, ok := err.(pcserror.Error)
-		if !ok {
-			// 未知错误类型 (非预期的)
-			// 不重试
-			result.ResultMessage = "上传文件错误"
-			result.Err = err
-			return
-		}
+		result.ResultMessage = StrUploadFailed
+		result.Err = err
 
-		// 默认需要重试
-		result.NeedRetry = true
+		action, wait := utu.retryPolicy().Classify(err)
+		utu.retryWait = wait
 
-		switch pcsError.GetErrType() {
-		case pcserror.ErrTypeRemoteError:
-			// 远程百度服务器的错误
-			switch pcsError.GetRemoteErrCode() {
-			case 31363:
-				// block miss in superfile2, 上传状态过期
-				// 需要重试的
-				utu.UploadingDatabase.Delete(&utu.LocalFileChecksum.LocalFileMeta)
-				utu.UploadingDatabase.Save()
-
-				result.ResultMessage = StrUploadFailed
-				result.Err = errors.New("上传状态过期, 重新上传")
-			case 31061:
-				// 已存在重名文件, 不重试
-				result.ResultMessage = StrUploadFailed
-				result.Err = pcsError
-				result.NeedRetry = false
-				return
-			default:
-				result.ResultMessage = StrUploadFailed
-				result.Err = pcsError
-			}
-		case pcserror.ErrTypeNetError:
-			// 网络错误
-			result.ResultMessage = StrUploadFailed
-			result.Err = pcsError
-			if strings.Contains(pcsError.GetError().Error(), "413 Request Entity Too Large") {
-				// 请求实体过大
-				// 不重试
-				result.NeedRetry = false
-				return
-			}
-		default:
-			result.ResultMessage = StrUploadFailed
+		switch action {
+		case retrypolicy.ActionRetryWithNewUploadID:
+			// 服务端分片状态已过期, 丢弃本地断点后重新上传
+			utu.UploadingDatabase.Delete(&utu.LocalFileChecksum.LocalFileMeta)
+			utu.saveUploadingDatabase()
+			utu.deleteRecorderState()
+
+			result.Err = errors.New("上传状态过期, 重新上传")
+			result.NeedRetry = true
+		case retrypolicy.ActionRetry, retrypolicy.ActionRetryAfterReauth:
+			result.NeedRetry = true
+		case retrypolicy.ActionSkip:
+			// 不视为失败, 例如Policy为skip时遇到的重名文件
+			utu.Printf("目标文件, %s, 已存在, 跳过...", utu.SavePath)
+			result.ResultMessage = ""
+			result.Err = nil
+			result.NeedRetry = false
+			result.Succeed = true
+		case retrypolicy.ActionFatal:
 			result.NeedRetry = false
-			result.Err = pcsError
 		}
-		return
 	})
 	muer.Execute()
 
@@ -319,6 +329,11 @@ func (utu *UploadTaskUnit) OnSuccess(lastRunResult *taskframework.TaskUnitRunRes
 
 func (utu *UploadTaskUnit) OnFailed(lastRunResult *taskframework.TaskUnitRunResult) {
 	// 失败
+	if !lastRunResult.NeedRetry {
+		// 不会再重试, 断点记录已无意义, 清理掉
+		utu.deleteRecorderState()
+	}
+
 	if lastRunResult.Err == nil {
 		// result中不包含Err, 忽略输出
 		utu.Printf("%s", lastRunResult.ResultMessage)
@@ -327,13 +342,108 @@ func (utu *UploadTaskUnit) OnFailed(lastRunResult *taskframework.TaskUnitRunResu
 	utu.Printf("%s, %s", lastRunResult.ResultMessage, lastRunResult.Err)
 }
 
+// saveRecorderState 将当前分片上传状态写入断点记录器
+func (utu *UploadTaskUnit) saveRecorderState(state *uploader.InstanceState) {
+	if utu.Recorder == nil || state == nil {
+		return
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	utu.Recorder.Set(utu.recorderKey, data)
+}
+
+// deleteRecorderState 清理断点记录, 在上传成功, 确认不再重试的失败, 或服务端分片状态过期需重新上传时调用
+func (utu *UploadTaskUnit) deleteRecorderState() {
+	if utu.Recorder == nil {
+		return
+	}
+	utu.Recorder.Delete(utu.recorderKey)
+}
+
+// fillFromDirCache 尝试从目录哈希缓存中直接取出MD5/SliceMD5, 命中时跳过对文件的完整读取
+func (utu *UploadTaskUnit) fillFromDirCache() bool {
+	if utu.DirCache == nil {
+		return false
+	}
+
+	fi, err := os.Stat(utu.LocalFileChecksum.Path)
+	if err != nil {
+		return false
+	}
+
+	entry, ok := utu.DirCache.Lookup(utu.RelPath, utu.LocalFileChecksum.Length, dircache.Mtime100Ns(fi.ModTime()))
+	if !ok {
+		return false
+	}
+
+	md5Bytes, err := hex.DecodeString(entry.MD5)
+	if err != nil {
+		return false
+	}
+	sliceMD5Bytes, err := hex.DecodeString(entry.SliceMD5)
+	if err != nil {
+		return false
+	}
+
+	utu.LocalFileChecksum.MD5 = md5Bytes
+	utu.LocalFileChecksum.SliceMD5 = sliceMD5Bytes
+	return true
+}
+
+// updateDirCache 将本次计算出的MD5/SliceMD5写回目录哈希缓存, 供后续重试/重新上传复用
+func (utu *UploadTaskUnit) updateDirCache() {
+	if utu.DirCache == nil {
+		return
+	}
+
+	fi, err := os.Stat(utu.LocalFileChecksum.Path)
+	if err != nil {
+		return
+	}
+
+	// Cache.Put内部会把这一条追加写入缓存文件, 这里不需要再显式Save一次,
+	// 避免目录文件数为N时产生O(N^2)的全量重写开销
+	utu.DirCache.Put(&dircache.Entry{
+		RelPath:    utu.RelPath,
+		Size:       utu.LocalFileChecksum.Length,
+		Mtime100Ns: dircache.Mtime100Ns(fi.ModTime()),
+		MD5:        hex.EncodeToString(utu.LocalFileChecksum.MD5),
+		SliceMD5:   hex.EncodeToString(utu.LocalFileChecksum.SliceMD5),
+	})
+}
+
 func (utu *UploadTaskUnit) OnComplete(lastRunResult *taskframework.TaskUnitRunResult) {
 }
 
 func (utu *UploadTaskUnit) RetryWait() time.Duration {
+	if utu.retryWait > 0 {
+		return utu.retryWait
+	}
 	return pcsfunctions.RetryWait(utu.taskInfo.Retry())
 }
 
+// retryPolicy 返回本任务使用的重试策略, 未显式设置时使用默认策略(并带上utu.Policy供31061判断使用)
+func (utu *UploadTaskUnit) retryPolicy() retrypolicy.Policy {
+	if utu.RetryPolicy == nil {
+		defaultPolicy := retrypolicy.NewDefaultPolicy()
+		defaultPolicy.RenamePolicy = utu.Policy
+		utu.RetryPolicy = defaultPolicy
+	}
+	return utu.RetryPolicy
+}
+
+// saveUploadingDatabase 保存断点续传数据库, BatchUploader并发上传多个文件时通过saveMu串行化写入
+func (utu *UploadTaskUnit) saveUploadingDatabase() {
+	if utu.saveMu != nil {
+		utu.saveMu.Lock()
+		defer utu.saveMu.Unlock()
+	}
+	utu.UploadingDatabase.Save()
+}
+
 func (utu *UploadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 	utu.Printf("准备上传: %s", utu.LocalFileChecksum.Path)
 
@@ -1,11 +1,14 @@
 package taskframework
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/mattn/go-isatty"
 	"github.com/mattn/go-runewidth"
 )
 
@@ -21,6 +24,31 @@ var Dot = Spinner{
 	FPS:    time.Second / 10,
 }
 
+// Mode 控制 TaskPrinter 的输出方式
+type Mode int
+
+const (
+	// ModeAuto 自动检测标准输出是否为终端: 是终端则按交互式光标重绘, 否则退化为 ModePlain,
+	// 避免在CI/`tee`/systemd等非终端环境下输出大量光标控制字符
+	ModeAuto Mode = iota
+	// ModePlain 仅追加输出, 不使用光标控制字符, 带节流
+	ModePlain
+	// ModeJSON 每次任务状态变化输出一个JSON对象, 供脚本/上层程序消费
+	ModeJSON
+)
+
+// plainRenderInterval ModePlain下两次输出之间的最小间隔, 避免刷新过于频繁
+const plainRenderInterval = 2 * time.Second
+
+// jsonEvent ModeJSON下单次状态变化对应的输出结构
+type jsonEvent struct {
+	TS    int64  `json:"ts"`
+	ID    string `json:"id"`
+	Stat  int    `json:"stat"`
+	Msg   string `json:"msg"`
+	Retry int    `json:"retry"`
+}
+
 type TaskPrinter struct {
 	wg           *sync.WaitGroup // 需确保主循环退出
 	Spinner      Spinner
@@ -31,6 +59,11 @@ type TaskPrinter struct {
 	tasks        []*taskState
 	multiLineMsg string
 	mask         []int // 记录上次每行输出宽度, 下次输出时用空格覆盖
+	lastPlainAt  time.Time
+	lastRows     int // 上一帧固定刷新区域(分隔线+任务行+汇总行)的行数, 不含最上方滚动的多行输出
+
+	MaxActiveRows int  // 同时展示的任务行数上限, 0表示不限制; 超出部分折叠进一条汇总行
+	Mode          Mode // 输出方式, 默认ModeAuto
 }
 
 type taskState struct {
@@ -62,10 +95,22 @@ func NewTaskPrinter() *TaskPrinter {
 		idMap:      make(map[string]int),
 		tasks:      nil,
 		quit:       false,
+		Mode:       ModeAuto,
 	}
 	return r
 }
 
+// resolveMode 将ModeAuto解析成实际生效的模式: 标准输出为终端时按ModeAuto(交互式)渲染, 否则退化为ModePlain
+func (p *TaskPrinter) resolveMode() Mode {
+	if p.Mode != ModeAuto {
+		return p.Mode
+	}
+	if isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		return ModeAuto
+	}
+	return ModePlain
+}
+
 func (p *TaskPrinter) SetTaskInfo(info *TaskInfo) {
 	p.idMap[info.Id()] = len(p.tasks)
 	p.tasks = append(p.tasks, &taskState{
@@ -107,7 +152,10 @@ func (p *TaskPrinter) Start() {
 	defer p.ticker.Stop()
 
 	for {
-		p.Render()
+		// ModeJSON按状态变化在Update中逐条输出, 不依赖定时重绘
+		if p.resolveMode() != ModeJSON {
+			p.Render()
+		}
 		select {
 		case <-p.ticker.C:
 			p.Update(spinnerMsg{})
@@ -140,6 +188,7 @@ func (p *TaskPrinter) Update(msg interface{}) {
 		i, ok := p.idMap[v.id]
 		if ok {
 			p.tasks[i].stat = v.stat
+			p.emitJSON(p.tasks[i])
 		}
 		return
 
@@ -153,6 +202,7 @@ func (p *TaskPrinter) Update(msg interface{}) {
 		if ok {
 			t := p.tasks[i]
 			t.msg = v.msg
+			p.emitJSON(t)
 		}
 		return
 
@@ -161,7 +211,131 @@ func (p *TaskPrinter) Update(msg interface{}) {
 	}
 }
 
+func (p *TaskPrinter) renderTaskLine(t *taskState) string {
+	s := ""
+	switch t.stat {
+	case TaskWait:
+		s += "-"
+	case TaskRun:
+		s += p.Spinner.String()
+	case TaskDone:
+		s += "√"
+	case TaskFail:
+		s += "X"
+	default:
+		s += " "
+	}
+
+	s += fmt.Sprintf(" [%s]", t.info.id)
+	if t.msg != "" {
+		s += " " + t.msg
+	}
+	if t.info.retry > 0 {
+		s += fmt.Sprintf(" (重试: %d/%d)", t.info.retry, t.info.maxRetry)
+	}
+	return s
+}
+
+// activeWindow 从全部任务中选出本帧要展示的MaxActiveRows个, 按stat优先级挑选
+// (进行中 > 等待中 > 已完成/失败), 而不是固定取注册顺序的前N个, 这样一个任务开始上传后
+// 总能换入窗口, 不会因为注册顺序靠后就永远被折叠进汇总行。返回顺序与p.tasks中的相对顺序一致。
+func (p *TaskPrinter) activeWindow() (shown, hidden []*taskState) {
+	if p.MaxActiveRows <= 0 || len(p.tasks) <= p.MaxActiveRows {
+		return p.tasks, nil
+	}
+
+	picked := make(map[*taskState]bool, p.MaxActiveRows)
+	pick := func(stat int) {
+		for _, t := range p.tasks {
+			if len(picked) >= p.MaxActiveRows {
+				return
+			}
+			if t.stat == stat && !picked[t] {
+				picked[t] = true
+			}
+		}
+	}
+	pick(TaskRun)
+	pick(TaskWait)
+	pick(TaskDone)
+	pick(TaskFail)
+
+	for _, t := range p.tasks {
+		if picked[t] {
+			shown = append(shown, t)
+		} else {
+			hidden = append(hidden, t)
+		}
+	}
+	return
+}
+
+// summaryLine 汇总被折叠任务的状态分布, 用于MaxActiveRows限制展示行数时的兜底提示
+func (p *TaskPrinter) summaryLine(hidden []*taskState) string {
+	var wait, run, done, fail int
+	for _, t := range hidden {
+		switch t.stat {
+		case TaskWait:
+			wait++
+		case TaskRun:
+			run++
+		case TaskDone:
+			done++
+		case TaskFail:
+			fail++
+		}
+	}
+	return fmt.Sprintf("... 还有 %d 个任务 (等待 %d, 进行中 %d, 完成 %d, 失败 %d)", len(hidden), wait, run, done, fail)
+}
+
+// emitJSON 在ModeJSON下, 将某个任务的一次状态变化输出为一行JSON
+func (p *TaskPrinter) emitJSON(t *taskState) {
+	if p.resolveMode() != ModeJSON {
+		return
+	}
+	data, err := json.Marshal(jsonEvent{
+		TS:    time.Now().Unix(),
+		ID:    t.info.id,
+		Stat:  t.stat,
+		Msg:   t.msg,
+		Retry: t.info.retry,
+	})
+	if err != nil {
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// Render 按当前生效的Mode渲染一帧输出; ModeJSON下状态变化已在emitJSON中输出, 此处不重复渲染
 func (p *TaskPrinter) Render() {
+	switch p.resolveMode() {
+	case ModePlain:
+		p.renderPlain()
+	case ModeJSON:
+		return
+	default:
+		p.renderTTY()
+	}
+}
+
+// renderPlain 仅追加输出, 不使用光标控制字符, 按plainRenderInterval节流以适应日志场景
+func (p *TaskPrinter) renderPlain() {
+	if !p.lastPlainAt.IsZero() && time.Since(p.lastPlainAt) < plainRenderInterval {
+		return
+	}
+	p.lastPlainAt = time.Now()
+
+	shown, hidden := p.activeWindow()
+	for _, t := range shown {
+		fmt.Println(p.renderTaskLine(t))
+	}
+	if len(hidden) > 0 {
+		fmt.Println(p.summaryLine(hidden))
+	}
+}
+
+// renderTTY 终端交互式渲染: 通过光标上移和空格覆盖实现原地刷新
+func (p *TaskPrinter) renderTTY() {
 	var strs []string
 	// 多行输出放在最上放，滚动更新
 	if p.multiLineMsg != "" {
@@ -169,37 +343,28 @@ func (p *TaskPrinter) Render() {
 		p.multiLineMsg = ""
 	}
 	strs = append(strs, "--------")
+
+	// 任务数较多时(如目录并发上传)只展示MaxActiveRows个活跃行, 其余折叠进汇总行, 避免刷屏
+	shown, hidden := p.activeWindow()
+
 	// 每个任务的状态，固定刷新
-	for _, t := range p.tasks {
-		s := ""
-		switch t.stat {
-		case TaskWait:
-			s += "-"
-		case TaskRun:
-			s += p.Spinner.String()
-		case TaskDone:
-			s += "√"
-		case TaskFail:
-			s += "X"
-		default:
-			s += " "
-		}
+	for _, t := range shown {
+		strs = append(strs, p.renderTaskLine(t))
+	}
 
-		s += fmt.Sprintf(" [%s]", t.info.id)
-		if t.msg != "" {
-			s += " " + t.msg
-		}
-		if t.info.retry > 0 {
-			s += fmt.Sprintf(" (重试: %d/%d)", t.info.retry, t.info.maxRetry)
-		}
-		strs = append(strs, s)
+	// 固定刷新区域: 分隔线 + 任务行 (+ 汇总行), 不含最上方滚动的多行输出
+	rows := 1 + len(shown)
+	if len(hidden) > 0 {
+		strs = append(strs, p.summaryLine(hidden))
+		rows++
 	}
 
 	buf := ""
 	newMask := make([]int, len(strs))
 	if p.mask != nil {
-		// 第一次输出, 无需移动光标
-		buf += fmt.Sprintf("\033[%dA", len(p.tasks)+1)
+		// 第一次输出, 无需移动光标; 行数可能随MaxActiveRows折叠而变化, 按上一帧固定刷新区域的实际行数移动光标,
+		// 多行输出留在上方滚动, 不被覆盖
+		buf += fmt.Sprintf("\033[%dA", p.lastRows)
 	}
 	for i, s := range strs {
 		l := runewidth.StringWidth(s)
@@ -213,6 +378,7 @@ func (p *TaskPrinter) Render() {
 	}
 	fmt.Print(buf)
 	p.mask = newMask
+	p.lastRows = rows
 }
 
 type Spinner struct {